@@ -0,0 +1,48 @@
+package goshin
+
+// Adapters giving the six built-in collectors a Name() and New() so they
+// satisfy Collector, and init() registrations so Start pulls them from the
+// registry instead of hardcoding them.
+
+type cpuCollector struct{ *CPUTime }
+
+func (cpuCollector) Name() string            { return "cpu" }
+func (cpuCollector) New(g *Goshin) Collector { return cpuCollector{NewCPUTime()} }
+
+type memoryCollector struct{ *MemoryUsage }
+
+func (memoryCollector) Name() string            { return "memory" }
+func (memoryCollector) New(g *Goshin) Collector { return memoryCollector{NewMemoryUsage()} }
+
+type loadCollector struct{ *LoadAverage }
+
+func (loadCollector) Name() string            { return "load" }
+func (loadCollector) New(g *Goshin) Collector { return loadCollector{NewLoadAverage()} }
+
+type netCollector struct{ *NetStats }
+
+func (netCollector) Name() string { return "net" }
+func (netCollector) New(g *Goshin) Collector {
+	return netCollector{NewNetStats(g.Ifaces, g.IgnoreIfaces)}
+}
+
+type diskCollector struct{ *DiskSpace }
+
+func (diskCollector) Name() string            { return "disk" }
+func (diskCollector) New(g *Goshin) Collector { return diskCollector{NewDiskSpace()} }
+
+type diskstatsCollector struct{ *DiskStats }
+
+func (diskstatsCollector) Name() string { return "diskstats" }
+func (diskstatsCollector) New(g *Goshin) Collector {
+	return diskstatsCollector{NewDiskStats(g.Devices, g.IgnoreDevices)}
+}
+
+func init() {
+	MustRegister(cpuCollector{})
+	MustRegister(memoryCollector{})
+	MustRegister(loadCollector{})
+	MustRegister(netCollector{})
+	MustRegister(diskCollector{})
+	MustRegister(diskstatsCollector{})
+}
@@ -0,0 +1,96 @@
+package goshin
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type sample struct {
+	value float64
+	at    time.Time
+}
+
+// deriver turns Counter/Derive metrics into per-second rates, similar to how
+// node_exporter and telegraf expose counters. It keeps the previous sample
+// per Service key so Report (via fanOut) can diff against it.
+type deriver struct {
+	mu      sync.Mutex
+	samples map[string]sample
+}
+
+func newDeriver() *deriver {
+	return &deriver{samples: make(map[string]sample)}
+}
+
+// apply rewrites metric.Value in place with (current-previous)/dt for
+// Counter/Derive metrics, leaving Gauge metrics untouched. It reports false
+// when the metric should not be forwarded: the first sample seen for a
+// service (no previous value to diff against yet), a non-positive dt, or a
+// Counter that went backwards without being near a wraparound boundary.
+func (d *deriver) apply(metric *Metric) bool {
+	if metric.Kind == Gauge {
+		return true
+	}
+
+	value, ok := toFloat(metric.Value)
+	if !ok {
+		return true
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	prev, seen := d.samples[metric.Service]
+	d.samples[metric.Service] = sample{value: value, at: now}
+	d.mu.Unlock()
+
+	if !seen {
+		return false
+	}
+
+	dt := now.Sub(prev.at).Seconds()
+	if dt <= 0 {
+		return false
+	}
+
+	delta := value - prev.value
+	if delta < 0 && metric.Kind == Counter {
+		width, ok := wraparoundWidth(prev.value)
+		if !ok {
+			// prev isn't plausibly close to a real rollover boundary, so this
+			// is far more likely an ordinary counter reset (interface
+			// replaced, monitored process restarted) than a genuine
+			// wraparound; treat it like a first sample rather than fabricate
+			// a multi-exabyte-per-second spike.
+			return false
+		}
+		delta += width
+	}
+
+	metric.Value = delta / dt
+	return true
+}
+
+// wraparoundMargin bounds how close prev must be to the top of its guessed
+// width (32-bit or 64-bit) before a negative delta is trusted as a real
+// counter rollover rather than a reset.
+const wraparoundMargin = 0.05
+
+// wraparoundWidth guesses how far a Counter rolled over past its width, based
+// on the magnitude of prev, the last sample seen before a negative delta. It
+// reports false unless prev is within wraparoundMargin of that width's top,
+// since a counter far from its max that still went backwards almost
+// certainly reset rather than wrapped.
+func wraparoundWidth(prev float64) (float64, bool) {
+	if prev <= math.MaxUint32 {
+		if prev >= math.MaxUint32*(1-wraparoundMargin) {
+			return math.MaxUint32, true
+		}
+		return 0, false
+	}
+	if prev >= math.MaxUint64*(1-wraparoundMargin) {
+		return math.MaxUint64, true
+	}
+	return 0, false
+}
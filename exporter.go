@@ -0,0 +1,153 @@
+package goshin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var metricNameRe = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// Exporter serves the metrics produced by a Goshin's collectors as
+// Prometheus/OpenMetrics text on /metrics, alongside the usual Riemann push.
+type Exporter struct {
+	addr string
+
+	mu      sync.Mutex
+	metrics map[string]*Metric
+
+	srv *http.Server
+}
+
+// NewExporter creates an Exporter that will listen on addr once ListenAndServe
+// is called. Use Attach to wire it into a Goshin's metric pipeline.
+func NewExporter(addr string) *Exporter {
+	return &Exporter{
+		addr:    addr,
+		metrics: make(map[string]*Metric),
+	}
+}
+
+// Attach fans every metric produced by g's enabled collectors out to e, in
+// addition to the metric still being pushed to Riemann via Report. It must be
+// called before g.Start.
+func (e *Exporter) Attach(g *Goshin) {
+	g.exportCh = make(chan *Metric, 100)
+	go e.consume(g.exportCh)
+}
+
+func (e *Exporter) consume(ch <-chan *Metric) {
+	for m := range ch {
+		e.mu.Lock()
+		e.metrics[m.Service] = m
+		e.mu.Unlock()
+	}
+}
+
+// ListenAndServe starts the HTTP server exposing /metrics. It blocks until the
+// server stops, mirroring http.Server.ListenAndServe.
+func (e *Exporter) ListenAndServe() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.handleMetrics)
+	e.srv = &http.Server{Addr: e.addr, Handler: mux}
+	return e.srv.ListenAndServe()
+}
+
+// Close shuts down the HTTP server, if it was started.
+func (e *Exporter) Close() error {
+	if e.srv == nil {
+		return nil
+	}
+	return e.srv.Close()
+}
+
+func (e *Exporter) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	metrics := make([]*Metric, 0, len(e.metrics))
+	for _, m := range e.metrics {
+		metrics = append(metrics, m)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Service < metrics[j].Service })
+
+	var buf bytes.Buffer
+	seen := make(map[string]bool)
+
+	for _, m := range metrics {
+		name := metricName(m.Service)
+
+		if value, ok := toFloat(m.Value); ok {
+			if !seen[name] {
+				fmt.Fprintf(&buf, "# HELP %s %s\n", name, helpText(m))
+				fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+				seen[name] = true
+			}
+			fmt.Fprintf(&buf, "%s{service=%q} %v\n", name, m.Service, value)
+		}
+
+		stateName := name + "_state"
+		if !seen[stateName] {
+			fmt.Fprintf(&buf, "# HELP %s threshold state as reported to Riemann (0=ok, 1=warning, 2=critical)\n", stateName)
+			fmt.Fprintf(&buf, "# TYPE %s gauge\n", stateName)
+			seen[stateName] = true
+		}
+		fmt.Fprintf(&buf, "%s{service=%q} %d\n", stateName, m.Service, stateValue(m.State))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write(buf.Bytes())
+		return
+	}
+
+	w.Write(buf.Bytes())
+}
+
+func helpText(m *Metric) string {
+	if m.Description == "" {
+		return fmt.Sprintf("Goshin metric for %s", m.Service)
+	}
+	return m.Description
+}
+
+func metricName(service string) string {
+	name := metricNameRe.ReplaceAllString(strings.ToLower(service), "_")
+	name = strings.Trim(name, "_")
+	return "goshin_" + name
+}
+
+func stateValue(state string) int {
+	switch state {
+	case "warning":
+		return 1
+	case "critical":
+		return 2
+	default:
+		return 0
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,68 @@
+package goshin
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestExporterConcurrentScrapeDuringReport wires an Exporter into a running
+// fanOut/Report pipeline and scrapes it concurrently with metrics flowing
+// through, to catch races between Report's per-metric work and the
+// exporter's HTTP handler reading the same *Metric (forward runs EnforceState
+// on it before handing it to both destinations). Run with -race.
+func TestExporterConcurrentScrapeDuringReport(t *testing.T) {
+	g := NewGoshin()
+	g.Interval = 1
+	g.ConnectionType = "tcp"
+	g.Address = "127.0.0.1:0" // nothing listening; Report stays buffering
+	g.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	exp := NewExporter("")
+	exp.Attach(g)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	collectQueue := make(chan *Metric, 100)
+	reportQueue := make(chan *Metric, 100)
+
+	go g.Report(ctx, reportQueue)
+	go g.fanOut(ctx, collectQueue, reportQueue)
+
+	srv := httptest.NewServer(http.HandlerFunc(exp.handleMetrics))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			m := NewMetric()
+			m.Service = "cpu"
+			m.Value = float64(i % 100)
+			collectQueue <- m
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Errorf("GET /metrics: %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	wg.Wait()
+}
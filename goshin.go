@@ -1,20 +1,38 @@
 package goshin
 
 import (
+	"context"
 	"fmt"
 	"github.com/amir/raidman"
 	"github.com/tjgq/broadcast"
-	"log/syslog"
+	"log/slog"
 	"math"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
-var logger, _ = syslog.New(syslog.LOG_DAEMON, "goshin")
+// MetricKind tells the reporting pipeline how to interpret Metric.Value.
+type MetricKind int
+
+const (
+	// Gauge values are reported as-is (e.g. load average, memory used %).
+	Gauge MetricKind = iota
+	// Counter values are monotonically increasing (e.g. bytes sent); they
+	// are converted to a per-second rate before being reported.
+	Counter
+	// Derive behaves like Counter but may also decrease between samples
+	// (e.g. a counter that gets reset); negative deltas are not treated
+	// as wraparound.
+	Derive
+)
 
 type Metric struct {
 	Service, Description, State string
 	Value                       interface{}
+	Kind                        MetricKind
 }
 
 func NewMetric() *Metric {
@@ -30,38 +48,87 @@ func NewThreshold() *Threshold {
 }
 
 type Goshin struct {
-	Address       string
-	EventHost     string
-	Interval      int
-	Tag           []string
-	Ttl           float32
-	Ifaces        map[string]bool
-	IgnoreIfaces  map[string]bool
-	Devices       map[string]bool
-	IgnoreDevices map[string]bool
-	Thresholds    map[string]*Threshold
-	Checks        map[string]bool
+	Address        string
+	EventHost      string
+	Interval       int
+	Tag            []string
+	Ttl            float32
+	Ifaces         map[string]bool
+	IgnoreIfaces   map[string]bool
+	Devices        map[string]bool
+	IgnoreDevices  map[string]bool
+	Thresholds     map[string]*Threshold
+	Checks         map[string]bool
 	ConnectionType string
-	Timeout int
+	Timeout        int
+
+	// HammerTime bounds, in seconds, how long Stop() waits for metrics
+	// still sitting in collectQueue to be flushed to Riemann before the
+	// connection is torn down (modeled after gitea's graceful package).
+	HammerTime int
+
+	// BufferSize caps how many metrics are held in memory while disconnected
+	// from Riemann. Zero means "use the default" (see bufferSize).
+	BufferSize int
+
+	// Logger receives Goshin's operational log lines. Defaults to a JSON
+	// handler on stderr in containers, text otherwise (see defaultLogger);
+	// embedders can set their own *slog.Logger, or use NewSyslogLogger for
+	// the previous log/syslog behavior.
+	Logger *slog.Logger
+
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+	exportCh chan *Metric
+	deriver  *deriver
 }
 
 func NewGoshin() *Goshin {
 	return &Goshin{
 		Thresholds: make(map[string]*Threshold),
+		HammerTime: 10,
+		deriver:    newDeriver(),
+		Logger:     defaultLogger(),
+	}
+}
+
+// Stop requests a graceful shutdown and blocks while collectQueue/reportQueue
+// are drained for HammerTime, then until Report has flushed what it buffered
+// and closed the Riemann connection.
+func (g *Goshin) Stop() {
+	if g.cancel == nil {
+		return
 	}
+	g.cancel()
+	<-g.stopped
 }
 
-func (g *Goshin) Start() {
-	defer logger.Close()
+// Start runs Goshin until ctx is cancelled, a SIGTERM/SIGHUP/SIGINT is
+// received, or Stop is called.
+func (g *Goshin) Start(ctx context.Context) {
+	if g.Logger == nil {
+		g.Logger = defaultLogger()
+	}
 
-	cputime := NewCPUTime()
-	memoryusage := NewMemoryUsage()
-	loadaverage := NewLoadAverage()
-	netstats := NewNetStats(g.Ifaces, g.IgnoreIfaces)
-	diskspace := NewDiskSpace()
-	diskstats := NewDiskStats(g.Devices, g.IgnoreDevices)
+	ctx, cancel := context.WithCancel(ctx)
+	g.cancel = cancel
+	g.stopped = make(chan struct{})
+	defer close(g.stopped)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT)
+	defer signal.Stop(sig)
+
+	go func() {
+		select {
+		case s := <-sig:
+			g.Logger.Info("received signal, shutting down", "signal", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	logger.Info(fmt.Sprintf("starting Goshin : will report each %d seconds", g.Interval))
+	g.Logger.Info("starting Goshin", "interval_seconds", g.Interval)
 
 	// channel size has to be large enough
 	// to allow Goshin send all metrics to Riemann
@@ -69,38 +136,104 @@ func (g *Goshin) Start() {
 	var collectQueue chan *Metric = make(chan *Metric, 100)
 
 	ticker := time.NewTicker(time.Second * time.Duration(g.Interval))
+	defer ticker.Stop()
 
 	b := broadcast.New(10)
+	defer b.Close()
 
-	if g.Checks["cpu"] {
-		logger.Debug("collector 'cpu' is enabled")
-		go cputime.Collect(collectQueue, b.Listen())
-	}
-	if g.Checks["memory"] {
-		logger.Debug("collector 'memory' is enabled")
-		go memoryusage.Collect(collectQueue, b.Listen())
+	for name, proto := range registry {
+		if !g.Checks[name] {
+			continue
+		}
+		g.Logger.Debug("collector enabled", "collector", name)
+		c := proto.New(g)
+		go c.Collect(collectQueue, b.Listen())
 	}
-	if g.Checks["load"] {
-		logger.Debug("collector 'load' is enabled")
-		go loadaverage.Collect(collectQueue, b.Listen())
+
+	reportQueue := make(chan *Metric, 100)
+	reportDone := make(chan struct{})
+	go func() {
+		g.Report(ctx, reportQueue)
+		close(reportDone)
+	}()
+	go g.fanOut(ctx, collectQueue, reportQueue)
+
+	for {
+		select {
+		case t := <-ticker.C:
+			b.Send(t)
+		case <-ctx.Done():
+			ticker.Stop()
+			// tell every collector's Collect loop to return
+			b.Send("quit")
+			// wait for Report to drain the queues and close the
+			// Riemann connection before Stop unblocks
+			<-reportDone
+			return
+		}
 	}
-	if g.Checks["net"] {
-		logger.Debug("collector 'net' is enabled")
-		go netstats.Collect(collectQueue, b.Listen())
+}
+
+// fanOut forwards every metric collected into collectQueue on to reportQueue
+// for Riemann, and additionally to g.exportCh (a no-op unless an Exporter has
+// been Attach()'d) so the Prometheus endpoint can serve the same data without
+// the collectors having to know about it.
+func (g *Goshin) fanOut(ctx context.Context, collectQueue, reportQueue chan *Metric) {
+	for {
+		select {
+		case <-ctx.Done():
+			// collectors are still winding down (they learn to stop via
+			// the broadcast "quit"), so keep forwarding whatever they
+			// still push into collectQueue instead of abandoning it.
+			g.drainCollectQueue(collectQueue, reportQueue)
+			return
+		case m := <-collectQueue:
+			g.forward(m, reportQueue)
+		}
 	}
-	if g.Checks["disk"] {
-		logger.Debug("collector 'disk' is enabled")
-		go diskspace.Collect(collectQueue, b.Listen())
+}
+
+// forward applies the derive/threshold pipeline to m and hands it to
+// reportQueue and, if attached, the exporter.
+func (g *Goshin) forward(m *Metric, reportQueue chan *Metric) {
+	// Counter/Derive metrics carry a raw monotonic value until turned
+	// into a per-second rate here; the first sample for a given service
+	// has no previous value to diff against and is dropped rather than
+	// reported as a nonsensical rate.
+	if !g.deriver.apply(m) {
+		return
 	}
-	if g.Checks["diskstats"] {
-		logger.Debug("collector 'diskstats' is enabled")
-		go diskstats.Collect(collectQueue, b.Listen())
+
+	g.EnforceState(m)
+	reportQueue <- m
+
+	if g.exportCh != nil {
+		select {
+		case g.exportCh <- m:
+		default:
+			// exporter is backlogged; drop rather than block Riemann reporting
+		}
 	}
+}
 
-	go g.Report(collectQueue)
+// drainCollectQueue keeps forwarding metrics out of collectQueue for up to
+// HammerTime, giving collectors time to push whatever they had in flight
+// before their Collect loop notices the shutdown broadcast and returns.
+func (g *Goshin) drainCollectQueue(collectQueue, reportQueue chan *Metric) {
+	deadline := time.Now().Add(time.Duration(g.HammerTime) * time.Second)
 
-	for t := range ticker.C {
-		b.Send(t)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return
+		}
+
+		select {
+		case m := <-collectQueue:
+			g.forward(m, reportQueue)
+		case <-time.After(remaining):
+			return
+		}
 	}
 }
 
@@ -133,46 +266,135 @@ func (g *Goshin) EnforceState(metric *Metric) {
 
 }
 
-func (g *Goshin) Report(reportQueue chan *Metric) {
+// Report sends metrics to Riemann, buffering them in a bounded ring buffer
+// whenever the connection is down and replaying that buffer once a
+// reconnector (see reconnect.go) brings it back up.
+func (g *Goshin) Report(ctx context.Context, reportQueue chan *Metric) {
 
-	connected := false
-	var connError error
-	var c *raidman.Client
+	buf := newRingBuffer(g.bufferSize())
+	conn := newConnector(ctx, g)
 
 	for {
-		if connected == false {
-			c, connError = raidman.DialWithTimeout(g.ConnectionType, g.Address, time.Duration(g.Timeout) * time.Second)
-		}
+		select {
+		case <-ctx.Done():
+			g.drainAndClose(reportQueue, conn, buf)
+			return
+		case metric := <-reportQueue:
+			c := conn.get()
+			if c == nil {
+				buf.push(metric)
+				continue
+			}
 
-		if connError != nil {
-			logger.Err(fmt.Sprintf("error : can not connect to host %s", g.Address))
-			connected = false
-		} else {
-			connected = true
-		}
+			if buf.len() > 0 {
+				g.replay(conn, buf)
+				c = conn.get()
+				if c == nil {
+					buf.push(metric)
+					continue
+				}
+			}
 
-		metric := <-reportQueue
-
-		if connected {
-			g.EnforceState(metric)
-			connError = c.Send(&raidman.Event{
-				Metric:      metric.Value,
-				Ttl:         g.Ttl,
-				Service:     metric.Service,
-				Description: metric.Description,
-				Tags:        g.Tag,
-				Host:        g.EventHost,
-				State:       metric.State})
-
-			if connError != nil {
-				logger.Err(fmt.Sprintf("error : %s", connError))
+			if err := c.Send(toEvent(g, metric)); err != nil {
+				g.Logger.Error("error sending event to riemann", "err", err)
 				c.Close()
-				connected = false
+				conn.invalidate()
+				buf.push(metric)
 			}
 		}
+	}
+}
+
+// drainAndClose keeps reading reportQueue for up to HammerTime seconds so
+// metrics produced while shutting down aren't lost, then makes a best-effort
+// attempt to flush everything buffered before closing the connection.
+func (g *Goshin) drainAndClose(reportQueue chan *Metric, conn *connector, buf *ringBuffer) {
+	timeout := time.After(time.Duration(g.HammerTime) * time.Second)
+
+drain:
+	for {
+		select {
+		case metric := <-reportQueue:
+			buf.push(metric)
+		case <-timeout:
+			break drain
+		}
+	}
 
-		metric = nil
+	if conn.get() == nil {
+		if n := buf.len(); n > 0 {
+			g.Logger.Info("shutting down disconnected from Riemann, dropping buffered metrics", "dropped", n)
+		}
+		return
+	}
+
+	g.replay(conn, buf)
+	// replay already closed and invalidated the connection if SendMulti
+	// failed; only close here if it's still the one we started with.
+	if c := conn.get(); c != nil {
+		c.Close()
+	}
+}
+
+// replay drains buf and ships it to Riemann in one SendMulti batch, followed
+// by a goshin.dropped counter if the buffer had to evict anything while full.
+// On failure the batch is pushed back onto buf and the connection is
+// invalidated so the reconnector retries.
+func (g *Goshin) replay(conn *connector, buf *ringBuffer) {
+	c := conn.get()
+	if c == nil {
+		return
+	}
+
+	pending := buf.drain()
+	if dropped := buf.droppedSinceLastReplay(); dropped > 0 {
+		dm := NewMetric()
+		dm.Service = "goshin.dropped"
+		dm.Value = float64(dropped)
+		pending = append(pending, dm)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	events := make([]*raidman.Event, len(pending))
+	for i, m := range pending {
+		events[i] = toEvent(g, m)
+	}
+
+	if err := c.SendMulti(events); err != nil {
+		g.Logger.Error("error replaying buffered events", "count", len(pending), "err", err)
+		c.Close()
+		conn.invalidate()
+		for _, m := range pending {
+			buf.push(m)
+		}
+	}
+}
+
+func toEvent(g *Goshin, m *Metric) *raidman.Event {
+	return &raidman.Event{
+		Metric:      m.Value,
+		Ttl:         g.Ttl,
+		Service:     m.Service,
+		Description: m.Description,
+		Tags:        g.Tag,
+		Host:        g.EventHost,
+		State:       m.State,
+	}
+}
+
+// bufferSize returns the configured BufferSize, or, if unset, a default of
+// ten times Interval (in seconds), but never less than 100.
+func (g *Goshin) bufferSize() int {
+	if g.BufferSize > 0 {
+		return g.BufferSize
+	}
+	size := g.Interval * 10
+	if size < 100 {
+		size = 100
 	}
+	return size
 }
 
 // https://gist.github.com/DavidVaini/10308388#gistcomment-1391788
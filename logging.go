@@ -0,0 +1,110 @@
+package goshin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"os"
+)
+
+// NewLogger builds a *slog.Logger writing to stderr. format is "json" or
+// "text"; anything else falls back to JSON.
+func NewLogger(format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+
+	return slog.New(handler)
+}
+
+// NewSyslogLogger builds a *slog.Logger that writes to the local syslog
+// daemon, kept for sites that relied on Goshin's previous log/syslog backend.
+// It fails, as the old package-level logger silently did not, on systems with
+// no syslog daemon running (containers, most notably) — callers that want
+// that environment should use NewLogger instead.
+func NewSyslogLogger(tag string, level slog.Level) (*slog.Logger, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(&syslogHandler{w: w, level: level}), nil
+}
+
+// defaultLogger mirrors node_exporter's promslog migration: JSON on stderr
+// when we're probably running under a container runtime that will scrape it
+// as structured logs, plain text otherwise.
+func defaultLogger() *slog.Logger {
+	if inContainer() {
+		return NewLogger("json", slog.LevelInfo)
+	}
+	return NewLogger("text", slog.LevelInfo)
+}
+
+func inContainer() bool {
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// syslogHandler adapts a *syslog.Writer to slog.Handler so NewSyslogLogger can
+// be used behind the same *slog.Logger interface as the stderr handlers.
+// syslog has no structured-field concept, so attrs accumulated via With and
+// WithGroup, plus the record's own attrs, are appended to the message as
+// "key=value" pairs rather than dropped.
+type syslogHandler struct {
+	w     *syslog.Writer
+	level slog.Level
+	group string
+	attrs []slog.Attr
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *syslogHandler) Handle(_ context.Context, r slog.Record) error {
+	msg := r.Message
+	for _, a := range h.attrs {
+		msg += " " + h.formatAttr(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		msg += " " + h.formatAttr(a)
+		return true
+	})
+
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.w.Err(msg)
+	case r.Level >= slog.LevelWarn:
+		return h.w.Warning(msg)
+	case r.Level >= slog.LevelInfo:
+		return h.w.Info(msg)
+	default:
+		return h.w.Debug(msg)
+	}
+}
+
+func (h *syslogHandler) formatAttr(a slog.Attr) string {
+	return fmt.Sprintf("%s%s=%v", h.group, a.Key, a.Value.Any())
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &next
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.group = h.group + name + "."
+	return &next
+}
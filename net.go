@@ -0,0 +1,86 @@
+package goshin
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NetStats reports per-interface traffic counters from /proc/net/dev.
+// Ifaces, if non-empty, restricts collection to the named interfaces;
+// IgnoreIfaces excludes interfaces that would otherwise be collected.
+type NetStats struct {
+	Ifaces       map[string]bool
+	IgnoreIfaces map[string]bool
+}
+
+func NewNetStats(ifaces, ignoreIfaces map[string]bool) *NetStats {
+	return &NetStats{Ifaces: ifaces, IgnoreIfaces: ignoreIfaces}
+}
+
+func (n *NetStats) Collect(out chan<- *Metric, tick <-chan interface{}) {
+	for v := range tick {
+		if s, ok := v.(string); ok && s == "quit" {
+			return
+		}
+		n.collect(out)
+	}
+}
+
+// collect reads /proc/net/dev once and reports rx/tx bytes and packets for
+// every interface that passes the Ifaces/IgnoreIfaces filter. Bytes and
+// packets are raw, ever-increasing kernel counters, so they're tagged
+// Counter and turned into per-second rates by the deriver before they reach
+// Riemann.
+func (n *NetStats) collect(out chan<- *Metric) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if line <= 2 {
+			// header lines
+			continue
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 11 {
+			continue
+		}
+
+		iface := strings.TrimSuffix(fields[0], ":")
+		if len(n.Ifaces) > 0 && !n.Ifaces[iface] {
+			continue
+		}
+		if n.IgnoreIfaces[iface] {
+			continue
+		}
+
+		rxBytes, err1 := strconv.ParseFloat(fields[1], 64)
+		rxPackets, err2 := strconv.ParseFloat(fields[2], 64)
+		txBytes, err3 := strconv.ParseFloat(fields[9], 64)
+		txPackets, err4 := strconv.ParseFloat(fields[10], 64)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+
+		out <- n.counter("net "+iface+" rx_bytes", rxBytes)
+		out <- n.counter("net "+iface+" rx_packets", rxPackets)
+		out <- n.counter("net "+iface+" tx_bytes", txBytes)
+		out <- n.counter("net "+iface+" tx_packets", txPackets)
+	}
+}
+
+func (n *NetStats) counter(service string, value float64) *Metric {
+	m := NewMetric()
+	m.Service = service
+	m.Value = value
+	m.Kind = Counter
+	return m
+}
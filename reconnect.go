@@ -0,0 +1,159 @@
+package goshin
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/amir/raidman"
+)
+
+const (
+	reconnectBase = time.Second
+	reconnectCap  = 60 * time.Second
+	jitterFrac    = 0.2
+)
+
+// connector keeps a *raidman.Client dialed in the background with an
+// exponential backoff (base 1s, cap 60s, ±20% jitter), so Report never blocks
+// the reportQueue consumer loop on a dead Riemann server.
+type connector struct {
+	mu     sync.Mutex
+	client *raidman.Client
+	retry  chan struct{}
+}
+
+func newConnector(ctx context.Context, g *Goshin) *connector {
+	c := &connector{retry: make(chan struct{}, 1)}
+	c.kick()
+	go c.run(ctx, g)
+	return c
+}
+
+func (c *connector) get() *raidman.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
+}
+
+// invalidate forgets the current client (Report is responsible for closing
+// it) and asks run to start dialing again.
+func (c *connector) invalidate() {
+	c.mu.Lock()
+	c.client = nil
+	c.mu.Unlock()
+	c.kick()
+}
+
+func (c *connector) kick() {
+	select {
+	case c.retry <- struct{}{}:
+	default:
+	}
+}
+
+func (c *connector) run(ctx context.Context, g *Goshin) {
+	backoff := reconnectBase
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.retry:
+		}
+
+		client, err := raidman.DialWithTimeout(g.ConnectionType, g.Address, time.Duration(g.Timeout)*time.Second)
+		if err != nil {
+			g.Logger.Error("can not connect to host", "addr", g.Address, "err", err)
+
+			select {
+			case <-time.After(jitter(backoff)):
+			case <-ctx.Done():
+				return
+			}
+
+			backoff *= 2
+			if backoff > reconnectCap {
+				backoff = reconnectCap
+			}
+			c.kick()
+			continue
+		}
+
+		backoff = reconnectBase
+		c.mu.Lock()
+		c.client = client
+		c.mu.Unlock()
+		g.Logger.Info("connected to riemann", "addr", g.Address)
+	}
+}
+
+// jitter returns d adjusted by up to ±jitterFrac, so many Goshin instances
+// reconnecting to the same dead Riemann server don't hammer it in lockstep.
+func jitter(d time.Duration) time.Duration {
+	delta := time.Duration(float64(d) * jitterFrac)
+	if delta <= 0 {
+		return d
+	}
+	return d - delta + time.Duration(rand.Int63n(int64(2*delta+1)))
+}
+
+// ringBuffer is a bounded, drop-oldest queue of *Metric used while Riemann is
+// unreachable. Pushing past size evicts the oldest entry and increments a
+// dropped counter that replay() surfaces as a goshin.dropped metric.
+type ringBuffer struct {
+	mu      sync.Mutex
+	items   []*Metric
+	size    int
+	start   int
+	count   int
+	dropped int
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{items: make([]*Metric, size), size: size}
+}
+
+func (r *ringBuffer) push(m *Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.count == r.size {
+		r.start = (r.start + 1) % r.size
+		r.count--
+		r.dropped++
+	}
+
+	r.items[(r.start+r.count)%r.size] = m
+	r.count++
+}
+
+func (r *ringBuffer) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// drain removes and returns every buffered metric, oldest first.
+func (r *ringBuffer) drain() []*Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]*Metric, r.count)
+	for i := 0; i < r.count; i++ {
+		out[i] = r.items[(r.start+i)%r.size]
+	}
+	r.start, r.count = 0, 0
+	return out
+}
+
+// droppedSinceLastReplay returns and resets the count of metrics evicted by
+// push since the last call.
+func (r *ringBuffer) droppedSinceLastReplay() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.dropped
+	r.dropped = 0
+	return d
+}
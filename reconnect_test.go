@@ -0,0 +1,89 @@
+package goshin
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRingBufferPushAndDrain(t *testing.T) {
+	buf := newRingBuffer(3)
+
+	for i := 0; i < 3; i++ {
+		m := NewMetric()
+		m.Value = float64(i)
+		buf.push(m)
+	}
+
+	if n := buf.len(); n != 3 {
+		t.Fatalf("len() = %d, want 3", n)
+	}
+
+	drained := buf.drain()
+	if len(drained) != 3 {
+		t.Fatalf("drain() returned %d metrics, want 3", len(drained))
+	}
+	for i, m := range drained {
+		if m.Value.(float64) != float64(i) {
+			t.Fatalf("drain()[%d] = %v, want %v (drain must preserve push order)", i, m.Value, i)
+		}
+	}
+
+	if n := buf.len(); n != 0 {
+		t.Fatalf("len() after drain = %d, want 0", n)
+	}
+}
+
+func TestRingBufferDropsOldestWhenFull(t *testing.T) {
+	buf := newRingBuffer(2)
+
+	for i := 0; i < 4; i++ {
+		m := NewMetric()
+		m.Value = float64(i)
+		buf.push(m)
+	}
+
+	if n := buf.droppedSinceLastReplay(); n != 2 {
+		t.Fatalf("droppedSinceLastReplay() = %d, want 2", n)
+	}
+	// the counter resets once read
+	if n := buf.droppedSinceLastReplay(); n != 0 {
+		t.Fatalf("droppedSinceLastReplay() after read = %d, want 0", n)
+	}
+
+	drained := buf.drain()
+	if len(drained) != 2 {
+		t.Fatalf("drain() returned %d metrics, want 2", len(drained))
+	}
+	if drained[0].Value.(float64) != 2 || drained[1].Value.(float64) != 3 {
+		t.Fatalf("drain() = %v, want the two most recently pushed values [2 3]", drained)
+	}
+}
+
+func TestDrainAndCloseWaitsForLateMetric(t *testing.T) {
+	g := &Goshin{HammerTime: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	reportQueue := make(chan *Metric, 1)
+	conn := &connector{}
+	buf := newRingBuffer(10)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m := NewMetric()
+		m.Service = "late"
+		reportQueue <- m
+	}()
+
+	start := time.Now()
+	g.drainAndClose(reportQueue, conn, buf)
+	elapsed := time.Since(start)
+
+	// A select with a ready `default` resolves in microseconds, well before
+	// the metric sent 50ms in could arrive; guard against that regression.
+	if elapsed < 50*time.Millisecond {
+		t.Fatalf("drainAndClose returned after %s, before the late metric could arrive", elapsed)
+	}
+	if n := buf.len(); n != 1 {
+		t.Fatalf("expected the late metric to have been drained into buf, got %d items", n)
+	}
+}
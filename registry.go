@@ -0,0 +1,45 @@
+package goshin
+
+import "fmt"
+
+// Collector is implemented by anything Start can schedule to produce
+// metrics on every tick. Third-party checks (nginx stub_status, postgres,
+// custom app probes) can live in their own package and Register a Collector
+// from an init() function, exactly like the built-in collectors in
+// builtin.go, without forking Goshin.
+type Collector interface {
+	// Name is the check name, the same key used in Goshin.Checks and as the
+	// registry key for this Collector.
+	Name() string
+
+	// New returns a fresh Collector configured against the Goshin instance
+	// that is about to run it, so collectors that need per-instance
+	// configuration (e.g. net's Ifaces/IgnoreIfaces) can read it off g.
+	New(g *Goshin) Collector
+
+	Collect(out chan<- *Metric, tick <-chan interface{})
+}
+
+var registry = make(map[string]Collector)
+
+// Register adds c to the registry under c.Name(). It returns an error if
+// that name is already registered, so two independently-authored
+// third-party collector packages can both try to register and decide for
+// themselves how to handle a clash.
+func Register(c Collector) error {
+	name := c.Name()
+	if _, exists := registry[name]; exists {
+		return fmt.Errorf("goshin: collector %q already registered", name)
+	}
+	registry[name] = c
+	return nil
+}
+
+// MustRegister is like Register but panics if name is already registered,
+// for init() call sites (like builtin.go's) that treat a clash as a
+// programming error rather than something to recover from.
+func MustRegister(c Collector) {
+	if err := Register(c); err != nil {
+		panic(err)
+	}
+}